@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jwtware "github.com/gofiber/contrib/jwt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// AuthMode selects how requests are authenticated.
+type AuthMode string
+
+const (
+	AuthModeJWT    AuthMode = "jwt"
+	AuthModeAPIKey AuthMode = "apikey"
+	AuthModeBoth   AuthMode = "both"
+)
+
+// authClaims is what we stash in c.Locals("user") once a request is authenticated.
+type authClaims struct {
+	Subject string   `json:"sub"`
+	Scopes  []string `json:"scope"`
+}
+
+func currentAuthMode() AuthMode {
+	switch AuthMode(strings.ToLower(os.Getenv("AUTH_MODE"))) {
+	case AuthModeJWT:
+		return AuthModeJWT
+	case AuthModeBoth:
+		return AuthModeBoth
+	default:
+		return AuthModeAPIKey
+	}
+}
+
+var (
+	jwtMiddlewareOnce sync.Once
+	jwtMiddlewareInst fiber.Handler
+)
+
+// sharedJWTMiddleware lazily builds the jwtware middleware once per process (not once
+// per route), since it holds a JWKS cache that shouldn't be re-created per call site.
+func sharedJWTMiddleware() fiber.Handler {
+	jwtMiddlewareOnce.Do(func() {
+		jwtMiddlewareInst = newJWTMiddleware(currentAuthMode())
+	})
+	return jwtMiddlewareInst
+}
+
+// authorizeRequest authenticates c according to AUTH_MODE and checks the resulting
+// claims against requiredScopes. On failure it writes the appropriate JSON error
+// response itself and returns ok=false, so callers (both static routes via requireAuth
+// and the dynamic service dispatcher) just need to stop without writing their own.
+func authorizeRequest(c *fiber.Ctx, requiredScopes ...string) (claims *authClaims, ok bool) {
+	mode := currentAuthMode()
+
+	claims, err := authenticate(c, mode, sharedJWTMiddleware())
+	if err != nil {
+		log.Printf("[SECURITY ALERT] traceID=%s Unauthorized access attempt from IP: %s: %v", traceIDFor(c), c.IP(), err)
+		c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Akses Ditolak. API Key tidak valid.",
+		})
+		return nil, false
+	}
+
+	if !hasAllScopes(claims.Scopes, requiredScopes) {
+		log.Printf("[SECURITY ALERT] traceID=%s Forbidden (missing scope) for subject %q from IP: %s", traceIDFor(c), claims.Subject, c.IP())
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Akses Ditolak. Scope tidak mencukupi.",
+		})
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// requireAuth builds a Fiber middleware that authenticates the request according to
+// AUTH_MODE and then enforces that the caller's scopes cover everything in requiredScopes.
+// Pass no scopes to only require a valid identity.
+func requireAuth(requiredScopes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := authorizeRequest(c, requiredScopes...)
+		if !ok {
+			return nil
+		}
+		c.Locals("user", claims)
+		return c.Next()
+	}
+}
+
+// authenticate tries the configured auth mode(s) in order and returns the resolved claims.
+func authenticate(c *fiber.Ctx, mode AuthMode, jwtMiddleware fiber.Handler) (*authClaims, error) {
+	if mode == AuthModeJWT || mode == AuthModeBoth {
+		if claims, err := authenticateJWT(c, jwtMiddleware); err == nil {
+			return claims, nil
+		} else if mode == AuthModeJWT {
+			return nil, err
+		}
+	}
+	return authenticateAPIKey(c)
+}
+
+// authenticateJWT runs the jwtware middleware in isolation so we can fall back to the
+// legacy API key when AUTH_MODE=both and no bearer token was presented.
+func authenticateJWT(c *fiber.Ctx, jwtMiddleware fiber.Handler) (*authClaims, error) {
+	if c.Get(fiber.HeaderAuthorization) == "" {
+		return nil, fiber.ErrUnauthorized
+	}
+
+	if err := jwtMiddleware(c); err != nil {
+		return nil, err
+	}
+
+	token, ok := c.Locals("jwtToken").(*jwt.Token)
+	if !ok {
+		return nil, fiber.ErrUnauthorized
+	}
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fiber.ErrUnauthorized
+	}
+
+	return claimsFromMap(mapClaims), nil
+}
+
+func authenticateAPIKey(c *fiber.Ctx) (*authClaims, error) {
+	key := c.Get("X-RIZ-KEY")
+	secret := os.Getenv("RIZ_SECRET_KEY")
+
+	if key == "" || key != secret {
+		return nil, fiber.ErrUnauthorized
+	}
+
+	return &authClaims{Subject: "apikey", Scopes: apiKeyScopes()}, nil
+}
+
+// apiKeyScopes lists the scopes granted to the legacy static API key, configurable via
+// API_KEY_SCOPES (comma separated). Defaults to "*", i.e. every scope, to preserve the
+// pre-existing "one key opens every door" behaviour.
+func apiKeyScopes() []string {
+	raw := os.Getenv("API_KEY_SCOPES")
+	if raw == "" {
+		return []string{"*"}
+	}
+	return splitAndTrim(raw)
+}
+
+func claimsFromMap(mapClaims jwt.MapClaims) *authClaims {
+	claims := &authClaims{}
+	if sub, ok := mapClaims["sub"].(string); ok {
+		claims.Subject = sub
+	}
+
+	switch scope := mapClaims["scope"].(type) {
+	case string:
+		claims.Scopes = splitAndTrim(scope)
+	case []interface{}:
+		for _, s := range scope {
+			if str, ok := s.(string); ok {
+				claims.Scopes = append(claims.Scopes, str)
+			}
+		}
+	}
+	return claims
+}
+
+func hasAllScopes(have []string, need []string) bool {
+	if len(need) == 0 {
+		return true
+	}
+	for _, h := range have {
+		if h == "*" {
+			return true
+		}
+	}
+	granted := make(map[string]bool, len(have))
+	for _, h := range have {
+		granted[h] = true
+	}
+	for _, n := range need {
+		if !granted[n] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitAndTrim(s string) []string {
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// newJWTMiddleware builds the jwtware middleware for the configured algorithm. HS256 uses
+// a static JWT_SECRET; RS256 fetches and auto-rotates public keys from a JWKS endpoint.
+func newJWTMiddleware(mode AuthMode) fiber.Handler {
+	if mode != AuthModeJWT && mode != AuthModeBoth {
+		return nil
+	}
+
+	cfg := jwtware.Config{
+		ContextKey: "jwtToken",
+		// Only stash the token; the caller decides what runs next.
+		SuccessHandler: func(c *fiber.Ctx) error {
+			return nil
+		},
+	}
+
+	switch strings.ToUpper(os.Getenv("JWT_ALG")) {
+	case "RS256":
+		jwksURL := os.Getenv("JWT_JWKS_URL")
+		keyFunc, err := jwksKeyFunc(jwksURL)
+		if err != nil {
+			log.Printf("[AUTH] Gagal memuat JWKS dari %s: %v", jwksURL, err)
+		}
+		cfg.KeyFunc = keyFunc
+	default:
+		cfg.SigningKey = jwtware.SigningKey{JWTAlg: "HS256", Key: []byte(os.Getenv("JWT_SECRET"))}
+	}
+
+	return jwtware.New(cfg)
+}
+
+// jwksKeyFunc resolves a kid from the incoming token against a cached, auto-refreshing
+// JWKS set, so key rotation on the identity provider's side doesn't require a redeploy.
+func jwksKeyFunc(jwksURL string) (jwt.Keyfunc, error) {
+	ctx := context.Background()
+	cache := jwk.NewCache(ctx)
+	if err := cache.Register(jwksURL, jwk.WithMinRefreshInterval(5*time.Minute)); err != nil {
+		return nil, err
+	}
+
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, fiber.ErrUnauthorized
+		}
+
+		set, err := cache.Get(ctx, jwksURL)
+		if err != nil {
+			return nil, err
+		}
+
+		key, ok := set.LookupKeyID(kid)
+		if !ok {
+			return nil, fiber.ErrUnauthorized
+		}
+
+		var raw rsa.PublicKey
+		if err := key.Raw(&raw); err != nil {
+			return nil, err
+		}
+		return &raw, nil
+	}, nil
+}
+
+// mintTokenHandler implements POST /v1/auth/token: clients that still hold the legacy
+// static API key exchange it here for a short-lived HS256 JWT, so they can migrate to the
+// scoped auth flow without a coordinated cutover.
+func mintTokenHandler(c *fiber.Ctx) error {
+	claims, err := authenticateAPIKey(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Akses Ditolak. API Key tidak valid.",
+		})
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "JWT_SECRET belum dikonfigurasi di server.",
+		})
+	}
+
+	ttl := 15 * time.Minute
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   claims.Subject,
+		"scope": strings.Join(claims.Scopes, ","),
+		"iat":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+	})
+
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Gagal membuat token.",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"access_token": signed,
+		"token_type":   "Bearer",
+		"expires_in":   int(ttl.Seconds()),
+	})
+}
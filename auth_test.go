@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestClaimsFromMap(t *testing.T) {
+	cases := []struct {
+		name    string
+		claims  jwt.MapClaims
+		subject string
+		scopes  []string
+	}{
+		{
+			name:    "string scope",
+			claims:  jwt.MapClaims{"sub": "user-1", "scope": "wa:send, mail:send"},
+			subject: "user-1",
+			scopes:  []string{"wa:send", "mail:send"},
+		},
+		{
+			name:    "array scope",
+			claims:  jwt.MapClaims{"sub": "user-2", "scope": []interface{}{"wa:send", "admin:cache"}},
+			subject: "user-2",
+			scopes:  []string{"wa:send", "admin:cache"},
+		},
+		{
+			name:    "missing scope",
+			claims:  jwt.MapClaims{"sub": "user-3"},
+			subject: "user-3",
+			scopes:  nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := claimsFromMap(tc.claims)
+			if claims.Subject != tc.subject {
+				t.Errorf("Subject = %q, want %q", claims.Subject, tc.subject)
+			}
+			if len(claims.Scopes) != len(tc.scopes) {
+				t.Fatalf("Scopes = %v, want %v", claims.Scopes, tc.scopes)
+			}
+			for i, s := range tc.scopes {
+				if claims.Scopes[i] != s {
+					t.Errorf("Scopes[%d] = %q, want %q", i, claims.Scopes[i], s)
+				}
+			}
+		})
+	}
+}
+
+func TestHasAllScopes(t *testing.T) {
+	cases := []struct {
+		name string
+		have []string
+		need []string
+		want bool
+	}{
+		{"no scopes required", []string{"wa:send"}, nil, true},
+		{"exact match", []string{"wa:send", "mail:send"}, []string{"wa:send"}, true},
+		{"missing scope", []string{"wa:send"}, []string{"mail:send"}, false},
+		{"wildcard grants everything", []string{"*"}, []string{"mail:send", "admin:cache"}, true},
+		{"partial overlap fails", []string{"wa:send"}, []string{"wa:send", "mail:send"}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := hasAllScopes(tc.have, tc.need); got != tc.want {
+				t.Errorf("hasAllScopes(%v, %v) = %v, want %v", tc.have, tc.need, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalanceStrategy selects which healthy upstream handles the next request.
+type BalanceStrategy string
+
+const (
+	StrategyRoundRobin BalanceStrategy = "round_robin"
+	StrategyRandom     BalanceStrategy = "random"
+	StrategyLeastConn  BalanceStrategy = "least_conn"
+	StrategyIPHash     BalanceStrategy = "ip_hash"
+)
+
+// backend is one upstream URL behind a Balancer, tracked for health, in-flight load, and
+// circuit-breaker state so a single bad instance can't take the whole service down.
+type backend struct {
+	url string
+
+	healthy  atomic.Bool
+	inFlight atomic.Int64
+	breaker  *circuitBreaker
+}
+
+// Balancer spreads requests for one service across its upstreams according to a
+// selectable strategy, skipping any backend the health checker or circuit breaker has
+// marked unavailable.
+type Balancer struct {
+	strategy BalanceStrategy
+	backends []*backend
+
+	mu      sync.Mutex
+	rrIndex int
+}
+
+func newBalancer(strategy BalanceStrategy, urls []string) *Balancer {
+	b := &Balancer{strategy: strategy}
+	for _, u := range urls {
+		be := &backend{url: u, breaker: newCircuitBreaker(5, 30*time.Second)}
+		be.healthy.Store(true)
+		b.backends = append(b.backends, be)
+	}
+	return b
+}
+
+// pick returns the backend that should handle the next request for key (the caller IP,
+// consulted by the ip_hash strategy), or nil if every backend is currently unavailable.
+func (b *Balancer) pick(key string) *backend {
+	available := make([]*backend, 0, len(b.backends))
+	for _, be := range b.backends {
+		if be.healthy.Load() && be.breaker.allow() {
+			available = append(available, be)
+		}
+	}
+	if len(available) == 0 {
+		return nil
+	}
+
+	switch b.strategy {
+	case StrategyRandom:
+		return available[rand.Intn(len(available))]
+	case StrategyLeastConn:
+		best := available[0]
+		for _, be := range available[1:] {
+			if be.inFlight.Load() < best.inFlight.Load() {
+				best = be
+			}
+		}
+		return best
+	case StrategyIPHash:
+		return available[fnvHash(key)%uint32(len(available))]
+	default: // round_robin
+		b.mu.Lock()
+		idx := b.rrIndex % len(available)
+		b.rrIndex++
+		b.mu.Unlock()
+		return available[idx]
+	}
+}
+
+func fnvHash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// startHealthChecks probes hc.Path on every backend every hc.IntervalSeconds, marking it
+// up/down based on the response. Returns a stop func; callers must invoke it when the
+// balancer is retired (e.g. on config reload) to avoid leaking the probe goroutines.
+func (b *Balancer) startHealthChecks(hc *HealthCheckConfig) func() {
+	if hc == nil || hc.Path == "" {
+		return func() {}
+	}
+
+	interval := time.Duration(hc.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	stop := make(chan struct{})
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	for _, be := range b.backends {
+		be := be
+		go func() {
+			probe := func() {
+				resp, err := client.Get(strings.TrimSuffix(be.url, "/") + hc.Path)
+				healthy := err == nil && resp.StatusCode < 500
+				if resp != nil {
+					resp.Body.Close()
+				}
+				if be.healthy.Swap(healthy) != healthy {
+					log.Printf("[HEALTHCHECK] %s sekarang %s", be.url, map[bool]string{true: "up", false: "down"}[healthy])
+				}
+			}
+
+			probe()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					probe()
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	return func() { close(stop) }
+}
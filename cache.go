@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cacheHitsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_cache_hits_total",
+		Help: "Cache hits for proxied GET/HEAD requests, by service.",
+	}, []string{"service"})
+
+	cacheMissesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_cache_misses_total",
+		Help: "Cache misses for proxied GET/HEAD requests, by service.",
+	}, []string{"service"})
+)
+
+// cacheEntry is one cached upstream response.
+type cacheEntry struct {
+	StatusCode           int
+	Header               http.Header
+	Body                 []byte
+	StoredAt             time.Time
+	MaxAge               time.Duration
+	StaleWhileRevalidate time.Duration
+	ETag                 string
+}
+
+func (e *cacheEntry) fresh() bool  { return time.Since(e.StoredAt) < e.MaxAge }
+func (e *cacheEntry) usable() bool { return time.Since(e.StoredAt) < e.MaxAge+e.StaleWhileRevalidate }
+
+// serviceCache wires a cacheBackend up with the bits that are independent of backend
+// choice: default TTL/stale-while-revalidate windows, and a small index remembering
+// which request headers each path's response Vary-ed on, so the *next* request can fold
+// them into its cache key instead of us needing to guess before the first fetch.
+type serviceCache struct {
+	backend cacheBackend
+	ttl     time.Duration
+	swr     time.Duration
+
+	varyMu    sync.Mutex
+	varyIndex map[string][]string // "METHOD /path" -> header names
+}
+
+func newServiceCache(cfg *CacheConfig) (*serviceCache, error) {
+	ttl := time.Duration(cfg.DefaultTTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = 60 * time.Second
+	}
+	swr := time.Duration(cfg.StaleWhileRevalidateSeconds) * time.Second
+
+	var backend cacheBackend
+	switch strings.ToLower(cfg.Backend) {
+	case "redis":
+		if cfg.RedisURL == "" {
+			return nil, fmt.Errorf("cache.backend=redis butuh cache.redis_url")
+		}
+		backend = newRedisCache(cfg.RedisURL)
+	case "", "memory":
+		backend = newMemoryCache()
+	default:
+		return nil, fmt.Errorf("cache.backend %q tidak dikenal", cfg.Backend)
+	}
+
+	return &serviceCache{backend: backend, ttl: ttl, swr: swr, varyIndex: make(map[string][]string)}, nil
+}
+
+func (sc *serviceCache) varyHeadersFor(method, path string) []string {
+	sc.varyMu.Lock()
+	defer sc.varyMu.Unlock()
+	return sc.varyIndex[method+" "+path]
+}
+
+func (sc *serviceCache) rememberVary(method, path string, headers []string) {
+	if len(headers) == 0 {
+		return
+	}
+	sc.varyMu.Lock()
+	defer sc.varyMu.Unlock()
+	sc.varyIndex[method+" "+path] = headers
+}
+
+// cacheKey is method+path+query+auth-subject, plus the value of any request header the
+// upstream previously told us (via Vary) that this path's response depends on.
+func cacheKey(c *fiber.Ctx, path, subject string, varyHeaders []string) string {
+	var b strings.Builder
+	b.WriteString(c.Method())
+	b.WriteByte('|')
+	b.WriteString(path)
+	b.WriteByte('|')
+	b.Write(c.Request().URI().QueryString())
+	b.WriteByte('|')
+	b.WriteString(subject)
+	for _, h := range varyHeaders {
+		b.WriteByte('|')
+		b.WriteString(h)
+		b.WriteByte('=')
+		b.WriteString(c.Get(h))
+	}
+	return b.String()
+}
+
+// cacheDirectives reads the bits of a response's Cache-Control/Vary headers that matter
+// to the cache layer: whether it may be stored at all, for how long, and which request
+// headers it varies on.
+func cacheDirectives(header http.Header, fallback time.Duration) (cacheable bool, maxAge time.Duration, vary []string) {
+	cacheable, maxAge = true, fallback
+
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache" || directive == "private":
+			cacheable = false
+		case strings.HasPrefix(directive, "max-age="):
+			if secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+				maxAge = time.Duration(secs) * time.Second
+			}
+		}
+	}
+
+	if v := header.Get("Vary"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				cacheable = false
+				continue
+			}
+			if name != "" {
+				vary = append(vary, name)
+			}
+		}
+	}
+
+	return cacheable, maxAge, vary
+}
+
+// serveCached serves path out of svc's cache when possible, otherwise forwards to the
+// upstream and stores the response for next time. Only called for GET/HEAD, per the
+// usual "only cache idempotent requests" rule.
+func serveCached(c *fiber.Ctx, svc *ServiceConfig, path, subject string) error {
+	sc := svc.cache
+	method := c.Method()
+
+	key := cacheKey(c, path, subject, sc.varyHeadersFor(method, path))
+
+	entry, ok := sc.backend.get(c.Context(), key)
+	if ok {
+		cacheHitsTotal.WithLabelValues(svc.Name).Inc()
+		writeCachedEntry(c, entry)
+		if !entry.fresh() {
+			// Stale but still usable: serve it immediately and refresh in the
+			// background rather than making the caller wait on the upstream.
+			go revalidate(svc, method, path, key, entry)
+		}
+		return nil
+	}
+
+	cacheMissesTotal.WithLabelValues(svc.Name).Inc()
+
+	resp, body, err := svc.fetchUpstream(c.Context(), method, path, nil)
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Gagal menghubungi upstream " + svc.Name + ".",
+		})
+	}
+
+	writeHTTPResponse(c, resp, body)
+	storeIfCacheable(sc, method, path, key, resp, body)
+	return nil
+}
+
+// revalidate refreshes a stale-while-revalidate cache entry in the background. It must
+// not touch the original *fiber.Ctx: fasthttp recycles it as soon as the handler that
+// served the stale copy returns, so a plain net/http round trip is used instead.
+func revalidate(svc *ServiceConfig, method, path, key string, stale *cacheEntry) {
+	header := http.Header{}
+	if stale.ETag != "" {
+		header.Set("If-None-Match", stale.ETag)
+	}
+
+	resp, body, err := svc.fetchUpstream(context.Background(), method, path, header)
+	if err != nil {
+		log.Printf("[CACHE] Gagal revalidate %s %s: %v", svc.Name, path, err)
+		return
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		// Still valid upstream: keep the stored response and restart its freshness window.
+		refreshed := *stale
+		refreshed.StoredAt = time.Now()
+		svc.cache.backend.set(context.Background(), key, &refreshed)
+		return
+	}
+
+	storeIfCacheable(svc.cache, method, path, key, resp, body)
+}
+
+func storeIfCacheable(sc *serviceCache, method, path, key string, resp *http.Response, body []byte) {
+	cacheable, maxAge, vary := cacheDirectives(resp.Header, sc.ttl)
+	if !cacheable || resp.StatusCode >= http.StatusInternalServerError {
+		return
+	}
+
+	sc.rememberVary(method, path, vary)
+
+	entry := &cacheEntry{
+		StatusCode:           resp.StatusCode,
+		Header:               resp.Header,
+		Body:                 body,
+		StoredAt:             time.Now(),
+		MaxAge:               maxAge,
+		StaleWhileRevalidate: sc.swr,
+		ETag:                 resp.Header.Get("ETag"),
+	}
+
+	sc.backend.set(context.Background(), key, entry)
+}
+
+func writeCachedEntry(c *fiber.Ctx, entry *cacheEntry) {
+	for k, vv := range entry.Header {
+		for _, v := range vv {
+			c.Response().Header.Add(k, v)
+		}
+	}
+	c.Status(entry.StatusCode)
+	c.Response().SetBody(entry.Body)
+}
+
+func writeHTTPResponse(c *fiber.Ctx, resp *http.Response, body []byte) {
+	for k, vv := range resp.Header {
+		for _, v := range vv {
+			c.Response().Header.Add(k, v)
+		}
+	}
+	c.Status(resp.StatusCode)
+	c.Response().SetBody(body)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// cacheBackend is the storage interface behind opt-in response caching; memoryCache and
+// redisCache both implement it so a service just picks one via config/gateway.yaml.
+type cacheBackend interface {
+	get(ctx context.Context, key string) (*cacheEntry, bool)
+	set(ctx context.Context, key string, entry *cacheEntry)
+	purge(ctx context.Context) error
+}
+
+// memoryCache is the default backend: an in-process map, good enough for a single
+// gateway instance or for services whose cache doesn't need to survive a restart.
+type memoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]*cacheEntry
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{entries: make(map[string]*cacheEntry)}
+}
+
+func (m *memoryCache) get(_ context.Context, key string) (*cacheEntry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[key]
+	if !ok || !entry.usable() {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (m *memoryCache) set(_ context.Context, key string, entry *cacheEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = entry
+}
+
+func (m *memoryCache) purge(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = make(map[string]*cacheEntry)
+	return nil
+}
+
+// redisCacheKeyPrefix namespaces every key this cache writes, so purge() can scan-and-delete
+// just the gateway's own entries instead of touching whatever else shares the Redis instance.
+const redisCacheKeyPrefix = "gw-cache:"
+
+// redisCache backs the cache with Redis, so multiple gateway replicas can share one
+// cache and survive individual instance restarts.
+type redisCache struct {
+	client *redis.Client
+}
+
+func newRedisCache(url string) *redisCache {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		opts = &redis.Options{Addr: url}
+	}
+	return &redisCache{client: redis.NewClient(opts)}
+}
+
+func (r *redisCache) get(ctx context.Context, key string) (*cacheEntry, bool) {
+	raw, err := r.client.Get(ctx, redisCacheKeyPrefix+key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, false
+	}
+	if !entry.usable() {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// minRedisCacheTTL is the floor applied to the computed Redis TTL. Without it, an upstream
+// response with MaxAge<=0 (e.g. "Cache-Control: max-age=0", which cacheDirectives treats as
+// cacheable, not no-store) and no stale-while-revalidate window would pass redis.Client.Set
+// a zero or negative duration - and per go-redis's own docs, "zero expiration means the key
+// has no expiration time" - leaking a permanent key that usable() just silently stops
+// returning instead of ever deleting.
+const minRedisCacheTTL = 1 * time.Second
+
+func (r *redisCache) set(ctx context.Context, key string, entry *cacheEntry) {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	ttl := entry.MaxAge + entry.StaleWhileRevalidate
+	if ttl < minRedisCacheTTL {
+		ttl = minRedisCacheTTL
+	}
+
+	r.client.Set(ctx, redisCacheKeyPrefix+key, raw, ttl)
+}
+
+// purge drops only this gateway's cache entries, scanning for redisCacheKeyPrefix rather
+// than FLUSHDB - cache.redis_url commonly points at a Redis instance shared with other
+// consumers, and FLUSHDB would wipe their keys too.
+func (r *redisCache) purge(ctx context.Context) error {
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, redisCacheKeyPrefix+"*", 100).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := r.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCacheDirectivesDefaultsToFallback(t *testing.T) {
+	header := http.Header{}
+	cacheable, maxAge, vary := cacheDirectives(header, 30*time.Second)
+
+	if !cacheable {
+		t.Error("response with no Cache-Control should be cacheable by default")
+	}
+	if maxAge != 30*time.Second {
+		t.Errorf("maxAge = %v, want %v", maxAge, 30*time.Second)
+	}
+	if vary != nil {
+		t.Errorf("vary = %v, want nil", vary)
+	}
+}
+
+func TestCacheDirectivesMaxAgeOverridesFallback(t *testing.T) {
+	header := http.Header{"Cache-Control": {"max-age=120"}}
+	cacheable, maxAge, _ := cacheDirectives(header, 30*time.Second)
+
+	if !cacheable {
+		t.Error("max-age alone should not make a response uncacheable")
+	}
+	if maxAge != 120*time.Second {
+		t.Errorf("maxAge = %v, want %v", maxAge, 120*time.Second)
+	}
+}
+
+func TestCacheDirectivesNoStoreIsUncacheable(t *testing.T) {
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		header := http.Header{"Cache-Control": {directive}}
+		if cacheable, _, _ := cacheDirectives(header, 30*time.Second); cacheable {
+			t.Errorf("Cache-Control: %s should make the response uncacheable", directive)
+		}
+	}
+}
+
+func TestCacheDirectivesVaryHeaders(t *testing.T) {
+	header := http.Header{"Vary": {"Authorization, Accept-Language"}}
+	_, _, vary := cacheDirectives(header, 30*time.Second)
+
+	want := []string{"Authorization", "Accept-Language"}
+	if len(vary) != len(want) {
+		t.Fatalf("vary = %v, want %v", vary, want)
+	}
+	for i, h := range want {
+		if vary[i] != h {
+			t.Errorf("vary[%d] = %q, want %q", i, vary[i], h)
+		}
+	}
+}
+
+func TestCacheDirectivesVaryStarIsUncacheable(t *testing.T) {
+	header := http.Header{"Vary": {"*"}}
+	if cacheable, _, _ := cacheDirectives(header, 30*time.Second); cacheable {
+		t.Error("Vary: * should make the response uncacheable")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTripsAfterThreshold(t *testing.T) {
+	cb := newCircuitBreaker(3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		cb.recordFailure()
+	}
+	if !cb.allow() {
+		t.Fatal("breaker should still be closed before reaching the failure threshold")
+	}
+
+	cb.recordFailure()
+	if cb.allow() {
+		t.Fatal("breaker should be open once the failure threshold is reached")
+	}
+	if got := cb.String(); got != "open" {
+		t.Errorf("String() = %q, want %q", got, "open")
+	}
+}
+
+func TestCircuitBreakerHalfOpenAfterCooldown(t *testing.T) {
+	cb := newCircuitBreaker(1, 10*time.Millisecond)
+
+	cb.recordFailure()
+	if cb.allow() {
+		t.Fatal("breaker should be open immediately after tripping")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if !cb.allow() {
+		t.Fatal("breaker should allow a probe request once the cooldown elapses")
+	}
+	if got := cb.String(); got != "half-open" {
+		t.Errorf("String() = %q, want %q", got, "half-open")
+	}
+}
+
+func TestCircuitBreakerClosesOnSuccess(t *testing.T) {
+	cb := newCircuitBreaker(1, 10*time.Millisecond)
+
+	cb.recordFailure()
+	time.Sleep(20 * time.Millisecond)
+	cb.allow() // flips to half-open
+
+	cb.recordSuccess()
+	if got := cb.String(); got != "closed" {
+		t.Errorf("String() = %q, want %q", got, "closed")
+	}
+}
+
+func TestCircuitBreakerReopensOnHalfOpenFailure(t *testing.T) {
+	cb := newCircuitBreaker(1, 10*time.Millisecond)
+
+	cb.recordFailure() // trips open
+	time.Sleep(20 * time.Millisecond)
+	cb.allow() // flips to half-open
+
+	cb.recordFailure() // probe failed
+	if cb.allow() {
+		t.Fatal("a failed probe in half-open should reopen the breaker, not reset the failure count")
+	}
+}
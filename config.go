@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GatewayConfig is the root of config/gateway.yaml: an arbitrary list of upstream
+// services the gateway should proxy to, replacing the old hardcoded WA/Mail handlers.
+type GatewayConfig struct {
+	Services []ServiceConfig `yaml:"services"`
+}
+
+// RateLimitConfig caps requests per caller for a single service, on top of (not instead
+// of) the global per-IP limiter installed on the whole app.
+type RateLimitConfig struct {
+	Max               int `yaml:"max"`
+	ExpirationSeconds int `yaml:"expiration_seconds"`
+}
+
+// HealthCheckConfig controls the background probe used to mark an upstream up/down.
+type HealthCheckConfig struct {
+	Path            string `yaml:"path"`
+	IntervalSeconds int    `yaml:"interval_seconds"`
+}
+
+// CacheConfig opts a service into response caching for its idempotent GET/HEAD calls.
+// Unset (nil) means caching stays off for that service, same as before this existed.
+type CacheConfig struct {
+	Enabled                     bool   `yaml:"enabled"`
+	Backend                     string `yaml:"backend"` // "memory" (default) or "redis"
+	RedisURL                    string `yaml:"redis_url"`
+	DefaultTTLSeconds           int    `yaml:"default_ttl_seconds"`
+	StaleWhileRevalidateSeconds int    `yaml:"stale_while_revalidate_seconds"`
+}
+
+// ServiceConfig describes one proxied backend: where its traffic comes in (PathPrefix),
+// where it goes (Upstreams), and the policy applied in between.
+type ServiceConfig struct {
+	Name        string             `yaml:"name"`
+	PathPrefix  string             `yaml:"path_prefix"`
+	Upstreams   []string           `yaml:"upstreams"`
+	Strategy    string             `yaml:"strategy"`
+	Scopes      []string           `yaml:"scopes"`
+	RateLimit   *RateLimitConfig   `yaml:"rate_limit"`
+	TimeoutMS   int                `yaml:"timeout_ms"`
+	StripPrefix bool               `yaml:"strip_prefix"`
+	HealthCheck *HealthCheckConfig `yaml:"health_check"`
+	Cache       *CacheConfig       `yaml:"cache"`
+
+	limiter  *perServiceLimiter
+	balancer *Balancer
+	cache    *serviceCache
+}
+
+// LoadGatewayConfig reads and validates config/gateway.yaml, wiring up per-service rate
+// limiters so they're ready to use as soon as the registry swaps them in.
+func LoadGatewayConfig(path string) (*GatewayConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca %s: %w", path, err)
+	}
+
+	// Let services reference upstream URLs via ${WA_SERVICE_URL}-style env vars, the
+	// same way the rest of the gateway is configured through the environment.
+	expanded := os.ExpandEnv(string(raw))
+
+	var cfg GatewayConfig
+	if err := yaml.Unmarshal([]byte(expanded), &cfg); err != nil {
+		return nil, fmt.Errorf("gagal parse %s: %w", path, err)
+	}
+
+	for i := range cfg.Services {
+		svc := &cfg.Services[i]
+		if svc.Name == "" {
+			return nil, fmt.Errorf("%s: service ke-%d tidak punya name", path, i)
+		}
+		if svc.PathPrefix == "" {
+			return nil, fmt.Errorf("%s: service %q tidak punya path_prefix", path, svc.Name)
+		}
+		if len(svc.Upstreams) == 0 {
+			return nil, fmt.Errorf("%s: service %q tidak punya upstreams", path, svc.Name)
+		}
+		if svc.RateLimit != nil && svc.RateLimit.Max > 0 {
+			svc.limiter = newPerServiceLimiter(svc.RateLimit.Max, svc.RateLimit.ExpirationSeconds)
+		}
+
+		strategy := BalanceStrategy(svc.Strategy)
+		switch strategy {
+		case StrategyRandom, StrategyLeastConn, StrategyIPHash:
+		default:
+			strategy = StrategyRoundRobin
+		}
+		svc.balancer = newBalancer(strategy, svc.Upstreams)
+
+		if svc.Cache != nil && svc.Cache.Enabled {
+			sc, err := newServiceCache(svc.Cache)
+			if err != nil {
+				return nil, fmt.Errorf("%s: service %q: %w", path, svc.Name, err)
+			}
+			svc.cache = sc
+		}
+	}
+
+	return &cfg, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"io/fs"
 	"log"
@@ -13,8 +14,6 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	"github.com/gofiber/fiber/v2/middleware/helmet"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
-	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/gofiber/fiber/v2/middleware/proxy"
 	"github.com/joho/godotenv"
 )
 
@@ -37,6 +36,19 @@ func main() {
 		log.Printf("SUCCESS: Embed berhasil membaca index.html (%d bytes)", len(testFile))
 	}
 
+	// Traces and metrics are useless if they don't outlive the process, so wire them up
+	// before anything that could fail fatally below.
+	shutdownTracing, err := initTracing()
+	if err != nil {
+		log.Printf("Peringatan: OpenTelemetry tracing gagal diinisialisasi: %v", err)
+	} else {
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				log.Printf("Peringatan: gagal shutdown tracer provider: %v", err)
+			}
+		}()
+	}
+
 	app := fiber.New(fiber.Config{
 		AppName:      "API Gateway",
 		ServerHeader: "By Rizky",
@@ -46,7 +58,9 @@ func main() {
 	// --- MIDDLEWARE KEAMANAN ---
 	app.Use(helmet.New())
 	app.Use(cors.New())
-	app.Use(logger.New())
+	app.Use(tracingMiddleware())
+	app.Use(loggerWithTraceID())
+	app.Use(prometheusMiddleware)
 
 	app.Use(limiter.New(limiter.Config{
 		Max:        50,
@@ -70,25 +84,44 @@ func main() {
 	}))
 
 	// --- ROUTE 2: API GATEWAY (THE GUARDIAN) ---
-	apiGateway := app.Group("/v1", authMiddleware)
+	apiGateway := app.Group("/v1")
+
+	// Mint short-lived JWTs for holders of the legacy static API key, so they can
+	// migrate to scoped auth without a coordinated cutover.
+	apiGateway.Post("/auth/token", mintTokenHandler)
+
+	// Services (WA, Mail, ...) are no longer hardcoded here: the registry loads them
+	// from config/gateway.yaml and hot-reloads on change, so operators can add/remove
+	// backends without redeploying the binary.
+	configPath := os.Getenv("GATEWAY_CONFIG_PATH")
+	if configPath == "" {
+		configPath = "config/gateway.yaml"
+	}
+	registry, err := NewServiceRegistry(configPath)
+	if err != nil {
+		log.Fatal("Gagal memuat konfigurasi gateway:", err)
+	}
 
-	// Forward ke WhatsApp Service
-	apiGateway.All("/wa/*", func(c *fiber.Ctx) error {
-		target := os.Getenv("WA_SERVICE_URL") + "/" + c.Params("*")
-		log.Printf("[PROXY] Forwarding to WhatsApp: %s", target)
-		return proxy.Do(c, target)
+	// Purge the response cache of every service that has it enabled.
+	apiGateway.Delete("/admin/cache", requireAuth("admin:cache"), func(c *fiber.Ctx) error {
+		if err := registry.PurgeCache(c.Context()); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"status": "purged"})
 	})
 
-	// Forward ke Mail Service
-	apiGateway.All("/mail/*", func(c *fiber.Ctx) error {
-		target := os.Getenv("MAIL_SERVICE_URL") + "/" + c.Params("*")
-		log.Printf("[PROXY] Forwarding to Mail: %s", target)
-		return proxy.Do(c, target)
-	})
+	apiGateway.All("/*", registry.Dispatch)
+
+	// Prometheus scrape endpoint
+	app.Get("/metrics", metricsHandler())
 
 	// Health Check
 	app.Get("/status", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"status": "Guarding", "uptime": "Active"})
+		return c.JSON(fiber.Map{
+			"status":   "Guarding",
+			"uptime":   "Active",
+			"services": registry.Status(),
+		})
 	})
 
 	port := os.Getenv("PORT")
@@ -99,16 +132,3 @@ func main() {
 	log.Printf("RizGate Ultimate berjalan di port %s", port)
 	log.Fatal(app.Listen(":" + port))
 }
-
-func authMiddleware(c *fiber.Ctx) error {
-	key := c.Get("X-RIZ-KEY")
-	secret := os.Getenv("RIZ_SECRET_KEY")
-
-	if key == "" || key != secret {
-		log.Printf("[SECURITY ALERT] Unauthorized access attempt from IP: %s", c.IP())
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Akses Ditolak. API Key tidak valid.",
-		})
-	}
-	return c.Next()
-}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// policyDecision is what policy.rego (or a remote OPA server) returns for one request:
+// whether it's allowed, and if not, why - so a 403 carries more than a bare status code.
+type policyDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// policyInput is everything policy.rego can reason about when deciding a request: method,
+// path, upstream service, caller identity/scopes, source IP, and time-of-day.
+type policyInput struct {
+	Method    string   `json:"method"`
+	Path      string   `json:"path"`
+	Service   string   `json:"service"`
+	Subject   string   `json:"subject"`
+	Scopes    []string `json:"scopes"`
+	SourceIP  string   `json:"source_ip"`
+	TimeOfDay string   `json:"time_of_day"` // "HH:MM", server local time
+}
+
+// PolicyEngine evaluates a policyInput against either a local policy.rego bundle or a
+// remote OPA server, selected by whether OPA_URL is configured.
+type PolicyEngine struct {
+	remoteURL string
+	query     rego.PreparedEvalQuery
+}
+
+// newPolicyEngine loads the policy from OPA_URL (a running OPA server) if set, otherwise
+// compiles the bundle at policyPath.
+func newPolicyEngine(policyPath string) (*PolicyEngine, error) {
+	if url := os.Getenv("OPA_URL"); url != "" {
+		return &PolicyEngine{remoteURL: url}, nil
+	}
+
+	prepared, err := rego.New(
+		rego.Query("data.gateway.authz"),
+		rego.Load([]string{policyPath}, nil),
+	).PrepareForEval(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("gagal memuat policy %s: %w", policyPath, err)
+	}
+
+	return &PolicyEngine{query: prepared}, nil
+}
+
+// Evaluate returns the policy's decision for input.
+func (p *PolicyEngine) Evaluate(ctx context.Context, input policyInput) (policyDecision, error) {
+	if p.remoteURL != "" {
+		return p.evaluateRemote(ctx, input)
+	}
+	return p.evaluateLocal(ctx, input)
+}
+
+func (p *PolicyEngine) evaluateLocal(ctx context.Context, input policyInput) (policyDecision, error) {
+	results, err := p.query.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return policyDecision{}, err
+	}
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		return policyDecision{}, fmt.Errorf("policy tidak menghasilkan keputusan")
+	}
+
+	decisionMap, ok := results[0].Expressions[0].Value.(map[string]interface{})
+	if !ok {
+		return policyDecision{}, fmt.Errorf("format keputusan policy tidak terduga")
+	}
+
+	var decision policyDecision
+	if allow, ok := decisionMap["allow"].(bool); ok {
+		decision.Allow = allow
+	}
+	if reason, ok := decisionMap["reason"].(string); ok {
+		decision.Reason = reason
+	}
+	return decision, nil
+}
+
+// evaluateRemote posts input to an external OPA server's Data API, for operators who run
+// OPA centrally instead of shipping policy.rego with the gateway binary.
+func (p *PolicyEngine) evaluateRemote(ctx context.Context, input policyInput) (policyDecision, error) {
+	payload, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return policyDecision{}, err
+	}
+
+	url := strings.TrimSuffix(p.remoteURL, "/") + "/v1/data/gateway/authz"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return policyDecision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return policyDecision{}, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Result policyDecision `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return policyDecision{}, err
+	}
+	return body.Result, nil
+}
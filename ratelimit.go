@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// perServiceLimiter rate-limits callers of a single proxied service, keyed by caller IP.
+// It's independent of the global limiter.New() on the whole app, since the config lets
+// each service declare its own, tighter or looser, limit.
+type perServiceLimiter struct {
+	refillEvery time.Duration
+	burst       int
+
+	// idleTTL and sweepEvery bound how long a per-IP limiter is kept once the IP stops
+	// sending requests, and how often allow() checks for ones to drop - without this, a
+	// public-facing gateway accumulates one *rate.Limiter per distinct caller forever.
+	idleTTL    time.Duration
+	sweepEvery time.Duration
+
+	mu        sync.Mutex
+	limiters  map[string]*limiterEntry
+	lastSweep time.Time
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newPerServiceLimiter(max int, expirationSeconds int) *perServiceLimiter {
+	if expirationSeconds <= 0 {
+		expirationSeconds = 60
+	}
+	expiration := time.Duration(expirationSeconds) * time.Second
+
+	return &perServiceLimiter{
+		refillEvery: expiration / time.Duration(max),
+		burst:       max,
+		idleTTL:     10 * expiration,
+		sweepEvery:  expiration,
+		limiters:    make(map[string]*limiterEntry),
+	}
+}
+
+// allow reports whether key (typically the caller's IP) is still within its quota.
+func (l *perServiceLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	entry, ok := l.limiters[key]
+	if !ok {
+		entry = &limiterEntry{limiter: rate.NewLimiter(rate.Every(l.refillEvery), l.burst)}
+		l.limiters[key] = entry
+	}
+	entry.lastSeen = now
+
+	if now.Sub(l.lastSweep) > l.sweepEvery {
+		l.sweep(now)
+		l.lastSweep = now
+	}
+
+	return entry.limiter.Allow()
+}
+
+// sweep drops limiters for callers that haven't been seen in idleTTL. Must be called with
+// l.mu held.
+func (l *perServiceLimiter) sweep(now time.Time) {
+	for key, entry := range l.limiters {
+		if now.Sub(entry.lastSeen) > l.idleTTL {
+			delete(l.limiters, key)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+)
+
+// ServiceRegistry holds the live, hot-reloadable table of proxied services and exposes
+// a single Fiber handler (Dispatch) that routes every /v1/* request to the matching one.
+// Swapping the table is just an atomic pointer store, so config/gateway.yaml can change
+// on disk without ever touching the underlying fiber.App route tree.
+type ServiceRegistry struct {
+	services atomic.Pointer[[]ServiceConfig]
+	policy   *PolicyEngine
+
+	healthCheckMu    sync.Mutex
+	stopHealthChecks []func()
+}
+
+// NewServiceRegistry loads configPath, starts watching it for changes, and returns a
+// registry ready to be wired into the app.
+func NewServiceRegistry(configPath string) (*ServiceRegistry, error) {
+	reg := &ServiceRegistry{}
+	if err := reg.reload(configPath); err != nil {
+		return nil, err
+	}
+
+	policyPath := os.Getenv("OPA_POLICY_PATH")
+	if policyPath == "" {
+		policyPath = "policy.rego"
+	}
+	policy, err := newPolicyEngine(policyPath)
+	if err != nil {
+		return nil, err
+	}
+	reg.policy = policy
+
+	go reg.watch(configPath)
+	return reg, nil
+}
+
+func (r *ServiceRegistry) reload(configPath string) error {
+	cfg, err := LoadGatewayConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	stops := make([]func(), 0, len(cfg.Services))
+	for i := range cfg.Services {
+		svc := &cfg.Services[i]
+		stops = append(stops, svc.balancer.startHealthChecks(svc.HealthCheck))
+	}
+
+	r.services.Store(&cfg.Services)
+
+	// Only stop the previous generation's probes once the new one is live, so there's
+	// never a gap where an upstream's health is unmonitored.
+	r.healthCheckMu.Lock()
+	previous := r.stopHealthChecks
+	r.stopHealthChecks = stops
+	r.healthCheckMu.Unlock()
+	for _, stop := range previous {
+		stop()
+	}
+
+	log.Printf("[REGISTRY] Memuat %d service dari %s", len(cfg.Services), configPath)
+	return nil
+}
+
+// watch rebuilds the route table whenever gateway.yaml changes, so operators can add or
+// remove backends without redeploying the binary.
+func (r *ServiceRegistry) watch(configPath string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[REGISTRY] Gagal membuat file watcher: %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(configPath); err != nil {
+		log.Printf("[REGISTRY] Gagal mengawasi %s: %v", configPath, err)
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			// Editors often replace the file (rename+create) rather than writing in
+			// place; give it a moment to settle before we try to read it.
+			time.Sleep(50 * time.Millisecond)
+			if err := r.reload(configPath); err != nil {
+				log.Printf("[REGISTRY] Gagal reload %s: %v", configPath, err)
+				continue
+			}
+			// Some editors drop the inode entirely; re-arm the watch just in case.
+			_ = watcher.Add(configPath)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[REGISTRY] File watcher error: %v", err)
+		}
+	}
+}
+
+// lookup returns the service whose path prefix matches path, preferring the longest
+// (most specific) prefix match. The prefix must land on a segment boundary - "/wa" matches
+// "/wa" and "/wa/send" but not "/waffle" - otherwise StripPrefix would chew into the next
+// segment and hand the upstream a malformed URL.
+func (r *ServiceRegistry) lookup(path string) *ServiceConfig {
+	services := r.services.Load()
+	if services == nil {
+		return nil
+	}
+
+	var best *ServiceConfig
+	for i := range *services {
+		svc := &(*services)[i]
+		if !strings.HasPrefix(path, svc.PathPrefix) {
+			continue
+		}
+		if rest := path[len(svc.PathPrefix):]; rest != "" && !strings.HasPrefix(rest, "/") {
+			continue
+		}
+		if best == nil || len(svc.PathPrefix) > len(best.PathPrefix) {
+			best = svc
+		}
+	}
+	return best
+}
+
+// Dispatch is the single Fiber handler registered for /v1/*. It resolves the target
+// service from the live route table, enforces its scopes and rate limit, and proxies.
+func (r *ServiceRegistry) Dispatch(c *fiber.Ctx) error {
+	path := "/" + c.Params("*")
+
+	svc := r.lookup(path)
+	if svc == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Service tidak ditemukan untuk path ini.",
+		})
+	}
+
+	if svc.limiter != nil && !svc.limiter.allow(c.IP()) {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "Terlalu banyak permintaan ke " + svc.Name + ".",
+		})
+	}
+
+	claims, ok := authorizeRequest(c, svc.Scopes...)
+	if !ok {
+		return nil
+	}
+	c.Locals("user", claims)
+
+	decision, err := r.policy.Evaluate(c.Context(), policyInput{
+		Method:    c.Method(),
+		Path:      path,
+		Service:   svc.Name,
+		Subject:   claims.Subject,
+		Scopes:    claims.Scopes,
+		SourceIP:  c.IP(),
+		TimeOfDay: time.Now().Format("15:04"),
+	})
+	if err != nil {
+		log.Printf("[SECURITY ALERT] traceID=%s Gagal evaluasi policy untuk %s: %v", traceIDFor(c), svc.Name, err)
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Akses ditolak oleh policy.",
+		})
+	}
+	if !decision.Allow {
+		log.Printf("[SECURITY ALERT] traceID=%s Policy menolak %s %s (subject=%s): %s", traceIDFor(c), c.Method(), path, claims.Subject, decision.Reason)
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": decision.Reason,
+		})
+	}
+
+	if svc.cache != nil && (c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead) {
+		return serveCached(c, svc, path, claims.Subject)
+	}
+	return svc.forward(c, path)
+}
+
+// PurgeCache drops every cached entry for every service that has caching enabled, for
+// the admin-gated DELETE /v1/admin/cache endpoint.
+func (r *ServiceRegistry) PurgeCache(ctx context.Context) error {
+	services := r.services.Load()
+	if services == nil {
+		return nil
+	}
+
+	for i := range *services {
+		svc := &(*services)[i]
+		if svc.cache == nil {
+			continue
+		}
+		if err := svc.cache.backend.purge(ctx); err != nil {
+			return fmt.Errorf("gagal purge cache %s: %w", svc.Name, err)
+		}
+	}
+	return nil
+}
+
+// targetURL builds the upstream URL for path on backend be, stripping s.PathPrefix first
+// when s.StripPrefix is set. Pulled out of fetchUpstream/forward so both proxy paths build
+// URLs identically.
+func (s *ServiceConfig) targetURL(be *backend, path string) string {
+	if s.StripPrefix {
+		path = strings.TrimPrefix(path, s.PathPrefix)
+	}
+	return strings.TrimSuffix(be.url, "/") + path
+}
+
+// fetchUpstream performs a single, non-streaming request to one of svc's upstreams. The
+// cache layer uses this instead of forward()'s streaming proxy.Do so it can inspect and
+// store the response body, and so a background revalidation isn't tied to a *fiber.Ctx
+// that fasthttp may have already recycled.
+func (s *ServiceConfig) fetchUpstream(ctx context.Context, method, path string, header http.Header) (*http.Response, []byte, error) {
+	be := s.balancer.pick("")
+	if be == nil {
+		return nil, nil, fmt.Errorf("semua upstream %s sedang tidak tersedia", s.Name)
+	}
+
+	target := s.targetURL(be, path)
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header != nil {
+		req.Header = header
+	}
+
+	timeout := 10 * time.Second
+	if s.TimeoutMS > 0 {
+		timeout = time.Duration(s.TimeoutMS) * time.Millisecond
+	}
+	client := &http.Client{Timeout: timeout}
+
+	be.inFlight.Add(1)
+	resp, err := client.Do(req)
+	be.inFlight.Add(-1)
+
+	outcome := "success"
+	if err != nil || (resp != nil && resp.StatusCode >= http.StatusInternalServerError) {
+		outcome = "failure"
+		be.breaker.recordFailure()
+	} else {
+		be.breaker.recordSuccess()
+	}
+	upstreamRequestsTotal.WithLabelValues(s.Name, be.url, outcome).Inc()
+
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, body, nil
+}
+
+// forward picks a healthy upstream via the service's balancer and proxies c to it,
+// short-circuiting with 503 if every upstream is down or its circuit breaker is open.
+func (s *ServiceConfig) forward(c *fiber.Ctx, path string) error {
+	be := s.balancer.pick(c.IP())
+	if be == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Semua upstream " + s.Name + " sedang tidak tersedia.",
+		})
+	}
+
+	target := s.targetURL(be, path)
+
+	log.Printf("[PROXY] traceID=%s Forwarding %s -> %s", traceIDFor(c), s.Name, target)
+
+	ctx, span := startProxySpan(c, s.Name, target)
+	c.SetUserContext(ctx)
+	defer span.End()
+
+	be.inFlight.Add(1)
+	defer be.inFlight.Add(-1)
+
+	var err error
+	if s.TimeoutMS > 0 {
+		err = proxy.DoTimeout(c, target, time.Duration(s.TimeoutMS)*time.Millisecond)
+	} else {
+		err = proxy.Do(c, target)
+	}
+
+	outcome := "success"
+	if err != nil || c.Response().StatusCode() >= fiber.StatusInternalServerError {
+		outcome = "failure"
+		be.breaker.recordFailure()
+		if err != nil {
+			span.RecordError(err)
+		}
+	} else {
+		be.breaker.recordSuccess()
+	}
+	upstreamRequestsTotal.WithLabelValues(s.Name, be.url, outcome).Inc()
+
+	return err
+}
+
+// Status reports the live pool state (health, in-flight count, breaker state) of every
+// configured service, for the /status endpoint.
+func (r *ServiceRegistry) Status() fiber.Map {
+	services := r.services.Load()
+	if services == nil {
+		return fiber.Map{}
+	}
+
+	out := fiber.Map{}
+	for i := range *services {
+		svc := &(*services)[i]
+		backends := make([]fiber.Map, 0, len(svc.balancer.backends))
+		for _, be := range svc.balancer.backends {
+			backends = append(backends, fiber.Map{
+				"url":       be.url,
+				"healthy":   be.healthy.Load(),
+				"in_flight": be.inFlight.Load(),
+				"breaker":   be.breaker.String(),
+			})
+		}
+		out[svc.Name] = fiber.Map{
+			"path_prefix": svc.PathPrefix,
+			"strategy":    string(svc.balancer.strategy),
+			"upstreams":   backends,
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+func newTestServiceConfig(name, prefix string, stripPrefix bool) ServiceConfig {
+	return ServiceConfig{
+		Name:        name,
+		PathPrefix:  prefix,
+		StripPrefix: stripPrefix,
+		balancer:    newBalancer(StrategyRoundRobin, []string{"http://upstream"}),
+	}
+}
+
+func newTestRegistry(services []ServiceConfig) *ServiceRegistry {
+	reg := &ServiceRegistry{}
+	reg.services.Store(&services)
+	return reg
+}
+
+func TestServiceRegistryLookupPrefixBoundary(t *testing.T) {
+	services := []ServiceConfig{
+		newTestServiceConfig("whatsapp", "/wa", true),
+		newTestServiceConfig("mail", "/mail", true),
+	}
+	reg := newTestRegistry(services)
+
+	cases := []struct {
+		path string
+		want string // service name, or "" for no match
+	}{
+		{"/wa", "whatsapp"},
+		{"/wa/send", "whatsapp"},
+		{"/waffle", ""},
+		{"/mail/status/1", "mail"},
+		{"/mailroom", ""},
+		{"/unknown", ""},
+	}
+
+	for _, tc := range cases {
+		svc := reg.lookup(tc.path)
+		if tc.want == "" {
+			if svc != nil {
+				t.Errorf("lookup(%q) = %q, want no match", tc.path, svc.Name)
+			}
+			continue
+		}
+		if svc == nil || svc.Name != tc.want {
+			t.Errorf("lookup(%q) = %v, want %q", tc.path, svc, tc.want)
+		}
+	}
+}
+
+func TestServiceRegistryLookupPrefersLongestMatch(t *testing.T) {
+	services := []ServiceConfig{
+		newTestServiceConfig("mail", "/mail", true),
+		newTestServiceConfig("mail-admin", "/mail/admin", true),
+	}
+	reg := newTestRegistry(services)
+
+	svc := reg.lookup("/mail/admin/users")
+	if svc == nil || svc.Name != "mail-admin" {
+		t.Errorf("lookup(%q) = %v, want %q (longest prefix)", "/mail/admin/users", svc, "mail-admin")
+	}
+}
+
+func TestServiceConfigTargetURLStripsPrefix(t *testing.T) {
+	svc := newTestServiceConfig("whatsapp", "/wa", true)
+	be := svc.balancer.backends[0]
+
+	got := svc.targetURL(be, "/wa/send")
+	want := "http://upstream/send"
+	if got != want {
+		t.Errorf("targetURL = %q, want %q", got, want)
+	}
+}
+
+func TestServiceConfigTargetURLKeepsPrefixWhenDisabled(t *testing.T) {
+	svc := newTestServiceConfig("whatsapp", "/wa", false)
+	be := svc.balancer.backends[0]
+
+	got := svc.targetURL(be, "/wa/send")
+	want := "http://upstream/wa/send"
+	if got != want {
+		t.Errorf("targetURL = %q, want %q", got, want)
+	}
+}
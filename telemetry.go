@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/contrib/otelfiber/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "golang-gateway-api"
+
+// Prometheus counters/histograms. Request-level metrics are recorded by
+// prometheusMiddleware; per-upstream outcomes are recorded from registry.go right after
+// each proxy.Do call, so a flapping backend shows up here before anyone notices in logs.
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_http_requests_total",
+		Help: "Total inbound requests handled by the gateway, labeled by route and status.",
+	}, []string{"method", "path", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_http_request_duration_seconds",
+		Help:    "Inbound request latency in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path"})
+
+	upstreamRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_requests_total",
+		Help: "Total proxied requests per upstream, labeled by outcome (success/failure).",
+	}, []string{"service", "upstream", "outcome"})
+)
+
+// initTracing wires up the global OTel tracer provider, exporting spans via OTLP/gRPC to
+// OTEL_EXPORTER_OTLP_ENDPOINT (defaults to the collector's standard local address). It
+// returns a shutdown func the caller should defer.
+func initTracing() (func(context.Context) error, error) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "localhost:4317"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	exporter, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName("golang-gateway-api"),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// fasthttpHeaderCarrier adapts fasthttp's request headers to otel's TextMapCarrier, so we
+// can inject the W3C traceparent header before forwarding a request upstream.
+type fasthttpHeaderCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (c fasthttpHeaderCarrier) Get(key string) string { return string(c.header.Peek(key)) }
+func (c fasthttpHeaderCarrier) Set(key, value string) { c.header.Set(key, value) }
+func (c fasthttpHeaderCarrier) Keys() []string {
+	keys := make([]string, 0)
+	c.header.VisitAll(func(k, _ []byte) { keys = append(keys, string(k)) })
+	return keys
+}
+
+// startProxySpan starts a child span for an outbound proxy.Do call and propagates the
+// resulting trace context into the upstream request's headers.
+func startProxySpan(c *fiber.Ctx, service, target string) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(c.UserContext(), "proxy."+service)
+	span.SetAttributes(
+		attribute.String("gateway.service", service),
+		attribute.String("http.url", target),
+	)
+
+	otel.GetTextMapPropagator().Inject(ctx, fasthttpHeaderCarrier{&c.Request().Header})
+	return ctx, span
+}
+
+// traceIDFor returns the current request's trace ID, or "-" if it isn't part of a
+// recorded trace, so [SECURITY ALERT] and [PROXY] log lines can be correlated against
+// the spans exported via OTLP.
+func traceIDFor(c *fiber.Ctx) string {
+	span := trace.SpanFromContext(c.UserContext())
+	if !span.SpanContext().HasTraceID() {
+		return "-"
+	}
+	return span.SpanContext().TraceID().String()
+}
+
+// tracingMiddleware emits a span per inbound request, propagating any traceparent header
+// the caller sent and making c.UserContext() carry it for the rest of the request.
+func tracingMiddleware() fiber.Handler {
+	return otelfiber.Middleware()
+}
+
+// prometheusMiddleware records request count and latency for every inbound request.
+func prometheusMiddleware(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	path := c.Route().Path
+	httpRequestsTotal.WithLabelValues(c.Method(), path, statusLabel(status)).Inc()
+	httpRequestDuration.WithLabelValues(c.Method(), path).Observe(time.Since(start).Seconds())
+
+	return err
+}
+
+func statusLabel(status int) string {
+	return strconv.Itoa(status)
+}
+
+// metricsHandler exposes the Prometheus registry at /metrics.
+func metricsHandler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
+
+// loggerWithTraceID mirrors the existing logger.New() setup but adds a traceID field to
+// every access log line so it can be correlated with distributed traces.
+func loggerWithTraceID() fiber.Handler {
+	return logger.New(logger.Config{
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} ${path} | traceID=${traceID}\n",
+		CustomTags: map[string]logger.LogFunc{
+			"traceID": func(output logger.Buffer, c *fiber.Ctx, data *logger.Data, extraParam string) (int, error) {
+				return output.WriteString(traceIDFor(c))
+			},
+		},
+	})
+}